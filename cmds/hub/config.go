@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bootConfig holds everything needed to bootstrap the hub, whether it came
+// from a flag, a config file, or a built-in default. Precedence is
+// flags > config file > defaults.
+type bootConfig struct {
+	LogLevel string `yaml:"log_level" json:"log_level"`
+
+	MetricsListen        string `yaml:"metrics_listen"          json:"metrics_listen"`
+	MetricsTLSCert       string `yaml:"metrics_tls_cert"        json:"metrics_tls_cert"`
+	MetricsTLSKey        string `yaml:"metrics_tls_key"         json:"metrics_tls_key"`
+	MetricsBasicAuthUser string `yaml:"metrics_basic_auth_user" json:"metrics_basic_auth_user"`
+	MetricsBasicAuthPass string `yaml:"metrics_basic_auth_pass" json:"metrics_basic_auth_pass"`
+
+	PprofListen        string `yaml:"pprof_listen"         json:"pprof_listen"`
+	PprofAllowPublic   bool   `yaml:"pprof_allow_public"   json:"pprof_allow_public"`
+	PprofBlockRate     int    `yaml:"pprof_block_rate"     json:"pprof_block_rate"`
+	PprofMutexFraction int    `yaml:"pprof_mutex_fraction" json:"pprof_mutex_fraction"`
+
+	RebootOnRestart bool          `yaml:"reboot_on_restart" json:"reboot_on_restart"`
+	PublicHub       bool          `yaml:"public_hub"        json:"public_hub"`
+	UpdateChannel   string        `yaml:"update_channel"    json:"update_channel"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"  json:"shutdown_timeout"`
+}
+
+// defaultBootConfig returns the built-in defaults used when neither a flag
+// nor the config file set a value.
+func defaultBootConfig() bootConfig {
+	return bootConfig{
+		LogLevel:        "warning",
+		PublicHub:       true,
+		UpdateChannel:   "stable",
+		ShutdownTimeout: 3 * time.Minute,
+	}
+}
+
+// fileBootConfig mirrors bootConfig but with pointer fields, so that
+// loadConfigFile can tell "not present in the file" apart from "present
+// with the zero value".
+type fileBootConfig struct {
+	LogLevel *string `yaml:"log_level" json:"log_level"`
+
+	MetricsListen        *string `yaml:"metrics_listen"          json:"metrics_listen"`
+	MetricsTLSCert       *string `yaml:"metrics_tls_cert"        json:"metrics_tls_cert"`
+	MetricsTLSKey        *string `yaml:"metrics_tls_key"         json:"metrics_tls_key"`
+	MetricsBasicAuthUser *string `yaml:"metrics_basic_auth_user" json:"metrics_basic_auth_user"`
+	MetricsBasicAuthPass *string `yaml:"metrics_basic_auth_pass" json:"metrics_basic_auth_pass"`
+
+	PprofListen        *string `yaml:"pprof_listen"         json:"pprof_listen"`
+	PprofAllowPublic   *bool   `yaml:"pprof_allow_public"   json:"pprof_allow_public"`
+	PprofBlockRate     *int    `yaml:"pprof_block_rate"     json:"pprof_block_rate"`
+	PprofMutexFraction *int    `yaml:"pprof_mutex_fraction" json:"pprof_mutex_fraction"`
+
+	RebootOnRestart *bool          `yaml:"reboot_on_restart" json:"reboot_on_restart"`
+	PublicHub       *bool          `yaml:"public_hub"        json:"public_hub"`
+	UpdateChannel   *string        `yaml:"update_channel"    json:"update_channel"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"  json:"shutdown_timeout"`
+}
+
+// buildConfig resolves the boot configuration from command line arguments
+// (and, if -config is given, a YAML or JSON file), applying the precedence
+// flags > config file > defaults. It also validates the resolved values,
+// e.g. that listen addresses parse and that the update channel is known.
+func buildConfig(args []string) (*bootConfig, error) {
+	fs := flag.NewFlagSet("hub", flag.ContinueOnError)
+
+	var configPath string
+	fs.StringVar(&configPath, "config", "", "path to a YAML or JSON bootstrap config file")
+
+	var flagVals bootConfig
+	fs.StringVar(&flagVals.LogLevel, "log-level", "", "log level (debug, info, warning, error)")
+	fs.StringVar(&flagVals.MetricsListen, "metrics-listen", "", "start a Prometheus metrics server on the given address, e.g. 127.0.0.1:9090")
+	fs.StringVar(&flagVals.MetricsTLSCert, "metrics-tls-cert", "", "path to a TLS certificate for the metrics server")
+	fs.StringVar(&flagVals.MetricsTLSKey, "metrics-tls-key", "", "path to the TLS key for the metrics server")
+	fs.StringVar(&flagVals.MetricsBasicAuthUser, "metrics-basic-auth-user", "", "require this username via HTTP basic auth on the metrics server")
+	fs.StringVar(&flagVals.MetricsBasicAuthPass, "metrics-basic-auth-pass", "", "require this password via HTTP basic auth on the metrics server")
+	fs.StringVar(&flagVals.PprofListen, "pprof-listen", "", "start a pprof/debug server on the given address, e.g. 127.0.0.1:6060")
+	fs.BoolVar(&flagVals.PprofAllowPublic, "pprof-allow-public", false, "allow -pprof-listen to bind to a non-loopback address")
+	fs.IntVar(&flagVals.PprofBlockRate, "pprof-block-rate", 0, "enable block profiling with the given sample rate (see runtime.SetBlockProfileRate)")
+	fs.IntVar(&flagVals.PprofMutexFraction, "pprof-mutex-fraction", 0, "enable mutex profiling with the given sample fraction (see runtime.SetMutexProfileFraction)")
+	fs.BoolVar(&flagVals.RebootOnRestart, "reboot-on-restart", false, "reboot server on auto-upgrade")
+	fs.BoolVar(&flagVals.PublicHub, "public-hub", false, "announce this hub publicly on the SPN")
+	fs.StringVar(&flagVals.UpdateChannel, "update-channel", "", "update channel (stable, beta, staging); it is validated but not passed on to the updater")
+	fs.DurationVar(&flagVals.ShutdownTimeout, "shutdown-timeout", 0, "time to wait for a graceful shutdown before forcing exit")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	cfg := defaultBootConfig()
+
+	if configPath != "" {
+		fileCfg, err := loadConfigFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", configPath, err)
+		}
+		applyFileConfig(&cfg, fileCfg)
+	}
+
+	applyFlagConfig(&cfg, &flagVals, explicit)
+
+	if err := validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// loadConfigFile reads and parses a YAML or JSON bootstrap config file,
+// selecting the format by file extension. Parse errors include the source
+// location reported by the underlying decoder.
+func loadConfigFile(path string) (*fileBootConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg fileBootConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid json at %s: %w", jsonErrorLocation(data, err), err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .json)", ext)
+	}
+
+	return &cfg, nil
+}
+
+// jsonErrorLocation converts a json.SyntaxError or json.UnmarshalTypeError
+// byte offset into a "line N, column M" string for error messages.
+func jsonErrorLocation(data []byte, err error) string {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return "unknown location"
+	}
+
+	line := 1
+	col := 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Sprintf("line %d, column %d", line, col)
+}
+
+// applyFileConfig overlays any fields present in the file onto cfg.
+func applyFileConfig(cfg *bootConfig, file *fileBootConfig) {
+	if file.LogLevel != nil {
+		cfg.LogLevel = *file.LogLevel
+	}
+	if file.MetricsListen != nil {
+		cfg.MetricsListen = *file.MetricsListen
+	}
+	if file.MetricsTLSCert != nil {
+		cfg.MetricsTLSCert = *file.MetricsTLSCert
+	}
+	if file.MetricsTLSKey != nil {
+		cfg.MetricsTLSKey = *file.MetricsTLSKey
+	}
+	if file.MetricsBasicAuthUser != nil {
+		cfg.MetricsBasicAuthUser = *file.MetricsBasicAuthUser
+	}
+	if file.MetricsBasicAuthPass != nil {
+		cfg.MetricsBasicAuthPass = *file.MetricsBasicAuthPass
+	}
+	if file.PprofListen != nil {
+		cfg.PprofListen = *file.PprofListen
+	}
+	if file.PprofAllowPublic != nil {
+		cfg.PprofAllowPublic = *file.PprofAllowPublic
+	}
+	if file.PprofBlockRate != nil {
+		cfg.PprofBlockRate = *file.PprofBlockRate
+	}
+	if file.PprofMutexFraction != nil {
+		cfg.PprofMutexFraction = *file.PprofMutexFraction
+	}
+	if file.RebootOnRestart != nil {
+		cfg.RebootOnRestart = *file.RebootOnRestart
+	}
+	if file.PublicHub != nil {
+		cfg.PublicHub = *file.PublicHub
+	}
+	if file.UpdateChannel != nil {
+		cfg.UpdateChannel = *file.UpdateChannel
+	}
+	if file.ShutdownTimeout != nil {
+		cfg.ShutdownTimeout = *file.ShutdownTimeout
+	}
+}
+
+// applyFlagConfig overlays only the flags that were explicitly set onto cfg.
+func applyFlagConfig(cfg *bootConfig, flagVals *bootConfig, explicit map[string]bool) {
+	if explicit["log-level"] {
+		cfg.LogLevel = flagVals.LogLevel
+	}
+	if explicit["metrics-listen"] {
+		cfg.MetricsListen = flagVals.MetricsListen
+	}
+	if explicit["metrics-tls-cert"] {
+		cfg.MetricsTLSCert = flagVals.MetricsTLSCert
+	}
+	if explicit["metrics-tls-key"] {
+		cfg.MetricsTLSKey = flagVals.MetricsTLSKey
+	}
+	if explicit["metrics-basic-auth-user"] {
+		cfg.MetricsBasicAuthUser = flagVals.MetricsBasicAuthUser
+	}
+	if explicit["metrics-basic-auth-pass"] {
+		cfg.MetricsBasicAuthPass = flagVals.MetricsBasicAuthPass
+	}
+	if explicit["pprof-listen"] {
+		cfg.PprofListen = flagVals.PprofListen
+	}
+	if explicit["pprof-allow-public"] {
+		cfg.PprofAllowPublic = flagVals.PprofAllowPublic
+	}
+	if explicit["pprof-block-rate"] {
+		cfg.PprofBlockRate = flagVals.PprofBlockRate
+	}
+	if explicit["pprof-mutex-fraction"] {
+		cfg.PprofMutexFraction = flagVals.PprofMutexFraction
+	}
+	if explicit["reboot-on-restart"] {
+		cfg.RebootOnRestart = flagVals.RebootOnRestart
+	}
+	if explicit["public-hub"] {
+		cfg.PublicHub = flagVals.PublicHub
+	}
+	if explicit["update-channel"] {
+		cfg.UpdateChannel = flagVals.UpdateChannel
+	}
+	if explicit["shutdown-timeout"] {
+		cfg.ShutdownTimeout = flagVals.ShutdownTimeout
+	}
+}
+
+var validUpdateChannels = map[string]bool{
+	"stable":  true,
+	"beta":    true,
+	"staging": true,
+}
+
+// validateConfig checks the resolved config for obviously invalid values
+// before spn.New() is called.
+func validateConfig(cfg *bootConfig) error {
+	if cfg.MetricsListen != "" {
+		if err := checkListenAddr(cfg.MetricsListen); err != nil {
+			return fmt.Errorf("invalid -metrics-listen address: %w", err)
+		}
+	}
+	if cfg.PprofListen != "" {
+		if err := checkListenAddr(cfg.PprofListen); err != nil {
+			return fmt.Errorf("invalid -pprof-listen address: %w", err)
+		}
+	}
+	if !validUpdateChannels[cfg.UpdateChannel] {
+		return fmt.Errorf("unknown update channel %q", cfg.UpdateChannel)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return fmt.Errorf("shutdown timeout must be positive, got %s", cfg.ShutdownTimeout)
+	}
+	return nil
+}
+
+// checkListenAddr verifies that addr has the "host:port" shape expected by
+// net/http.Server.Addr.
+func checkListenAddr(addr string) error {
+	_, _, err := net.SplitHostPort(addr)
+	return err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildConfigDefaults(t *testing.T) {
+	cfg, err := buildConfig(nil)
+	if err != nil {
+		t.Fatalf("buildConfig() error = %v", err)
+	}
+	if cfg.UpdateChannel != "stable" {
+		t.Errorf("UpdateChannel = %q, want %q", cfg.UpdateChannel, "stable")
+	}
+	if cfg.ShutdownTimeout != 3*time.Minute {
+		t.Errorf("ShutdownTimeout = %s, want %s", cfg.ShutdownTimeout, 3*time.Minute)
+	}
+}
+
+func TestBuildConfigFlagOverridesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hub.yaml")
+	writeFile(t, path, "update_channel: beta\nshutdown_timeout: 30s\n")
+
+	cfg, err := buildConfig([]string{"-config", path, "-update-channel", "staging"})
+	if err != nil {
+		t.Fatalf("buildConfig() error = %v", err)
+	}
+
+	if cfg.UpdateChannel != "staging" {
+		t.Errorf("UpdateChannel = %q, want %q (flag should win over file)", cfg.UpdateChannel, "staging")
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %s, want %s (file should win over default)", cfg.ShutdownTimeout, 30*time.Second)
+	}
+}
+
+func TestBuildConfigJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hub.json")
+	writeFile(t, path, `{"update_channel": "beta", "metrics_listen": "127.0.0.1:9090"}`)
+
+	cfg, err := buildConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("buildConfig() error = %v", err)
+	}
+
+	if cfg.UpdateChannel != "beta" {
+		t.Errorf("UpdateChannel = %q, want %q", cfg.UpdateChannel, "beta")
+	}
+	if cfg.MetricsListen != "127.0.0.1:9090" {
+		t.Errorf("MetricsListen = %q, want %q", cfg.MetricsListen, "127.0.0.1:9090")
+	}
+}
+
+func TestBuildConfigMalformedYAMLReportsLocation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hub.yaml")
+	writeFile(t, path, "update_channel: beta\n  bad_indent: true\n")
+
+	_, err := buildConfig([]string{"-config", path})
+	if err == nil {
+		t.Fatal("buildConfig() error = nil, want an error for malformed YAML")
+	}
+	if !strings.Contains(err.Error(), "line") {
+		t.Errorf("error %q does not mention a source line", err)
+	}
+}
+
+func TestBuildConfigMalformedJSONReportsLocation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hub.json")
+	writeFile(t, path, `{"update_channel": beta}`)
+
+	_, err := buildConfig([]string{"-config", path})
+	if err == nil {
+		t.Fatal("buildConfig() error = nil, want an error for malformed JSON")
+	}
+	if !strings.Contains(err.Error(), "line") {
+		t.Errorf("error %q does not mention a source line", err)
+	}
+}
+
+func TestBuildConfigRejectsUnknownUpdateChannel(t *testing.T) {
+	_, err := buildConfig([]string{"-update-channel", "nightly"})
+	if err == nil {
+		t.Fatal("buildConfig() error = nil, want an error for unknown update channel")
+	}
+}
+
+func TestBuildConfigRejectsInvalidListenAddr(t *testing.T) {
+	_, err := buildConfig([]string{"-metrics-listen", "not-a-valid-addr"})
+	if err == nil {
+		t.Fatal("buildConfig() error = nil, want an error for invalid -metrics-listen")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
 	"log/slog"
@@ -17,20 +17,21 @@ import (
 	"github.com/safing/portmaster/base/log"
 	"github.com/safing/portmaster/base/metrics"
 	"github.com/safing/portmaster/service/mgr"
+	"github.com/safing/portmaster/service/sdnotify"
 	"github.com/safing/portmaster/service/updates"
 	"github.com/safing/portmaster/service/updates/helper"
 	"github.com/safing/portmaster/spn"
 	"github.com/safing/portmaster/spn/conf"
 )
 
-func init() {
-	flag.BoolVar(&updates.RebootOnRestart, "reboot-on-restart", false, "reboot server on auto-upgrade")
-}
-
 var sigUSR1 = syscall.Signal(0xa)
 
 func main() {
-	flag.Parse()
+	cfg, err := buildConfig(os.Args[1:])
+	if err != nil {
+		fmt.Printf("invalid configuration: %s\n", err)
+		os.Exit(2)
+	}
 
 	// Set name and license.
 	info.Set("SPN Hub", "0.7.8", "GPLv3")
@@ -40,10 +41,11 @@ func main() {
 
 	// Configure user agent and updates.
 	updates.UserAgent = fmt.Sprintf("SPN Hub (%s %s)", runtime.GOOS, runtime.GOARCH)
+	updates.RebootOnRestart = cfg.RebootOnRestart
 	helper.IntelOnly()
 
 	// Set SPN public hub mode.
-	conf.EnablePublicHub(true)
+	conf.EnablePublicHub(cfg.PublicHub)
 
 	// Create instance.
 	var execCmdLine bool
@@ -75,10 +77,25 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Set default log level.
-	log.SetLogLevel(log.WarningLevel)
+	// Set log level.
+	log.SetLogLevel(parseLogLevel(cfg.LogLevel))
 	_ = log.Start()
 
+	_, _ = sdnotify.Status("connecting SPN")
+
+	// Start the metrics server before the instance so orchestrators can
+	// observe startup via /health and /ready.
+	metricsServer, err := startMetricsServer(cfg, instance)
+	if err != nil {
+		fmt.Printf("failed to start metrics server: %s\n", err)
+		os.Exit(2)
+	}
+	pprofServer, err := startPprofServer(cfg)
+	if err != nil {
+		fmt.Printf("failed to start pprof server: %s\n", err)
+		os.Exit(2)
+	}
+
 	// Start
 	go func() {
 		err = instance.Start()
@@ -88,6 +105,30 @@ func main() {
 		}
 	}()
 
+	// Notify systemd once the instance is ready, and feed its watchdog until
+	// shutdown begins. Both are no-ops without systemd.
+	watchdogCtx, cancelWatchdog := context.WithCancel(context.Background())
+	defer cancelWatchdog()
+	sdnotify.StartWatchdog(watchdogCtx)
+	go func() {
+		// instance.Ready() is a plain bool, not a channel, so poll it until
+		// it flips or the instance stops.
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-instance.Stopped():
+				return
+			case <-ticker.C:
+				if instance.Ready() {
+					_, _ = sdnotify.Status("running")
+					_, _ = sdnotify.Ready()
+					return
+				}
+			}
+		}
+	}()
+
 	// Wait for signal.
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(
@@ -111,10 +152,17 @@ func main() {
 		}
 
 	case <-instance.Stopped():
+		stopMetricsServer(metricsServer)
+		stopPprofServer(pprofServer)
 		log.Shutdown()
 		os.Exit(instance.ExitCode())
 	}
 
+	// Tell systemd we're shutting down.
+	_, _ = sdnotify.Stopping()
+	_, _ = sdnotify.Status("shutting down")
+	cancelWatchdog()
+
 	// Catch signals during shutdown.
 	// Rapid unplanned disassembly after 5 interrupts.
 	go func() {
@@ -131,9 +179,20 @@ func main() {
 		}
 	}()
 
-	// Rapid unplanned disassembly after 3 minutes.
+	// Give the instance -shutdown-timeout to stop gracefully (default 3m),
+	// or less if systemd's watchdog would otherwise kill us first.
+	//
+	// This is a process-wide timer, not a deadline propagated into each
+	// mgr.Go worker (e.g. packetHandler, bandwidthUpdateHandler, statLogger),
+	// and the forced exit below does not list which workers are still
+	// running. Neither service/mgr nor spn.Instance expose that per-worker
+	// visibility from this package, so this stays a stopgap until they do.
+	hardShutdownTimeout := cfg.ShutdownTimeout
+	if watchdogInterval, ok := sdnotify.WatchdogInterval(); ok && watchdogInterval < hardShutdownTimeout {
+		hardShutdownTimeout = watchdogInterval
+	}
 	go func() {
-		time.Sleep(3 * time.Minute)
+		time.Sleep(hardShutdownTimeout)
 		printStackTo(os.Stderr, "PRINTING STACK - TAKING TOO LONG FOR SHUTDOWN")
 		os.Exit(1)
 	}()
@@ -142,10 +201,30 @@ func main() {
 	if err := instance.Stop(); err != nil {
 		slog.Error("failed to stop", "err", err)
 	}
+	stopMetricsServer(metricsServer)
+	stopPprofServer(pprofServer)
 	log.Shutdown()
 	os.Exit(instance.ExitCode())
 }
 
+// parseLogLevel maps a config/flag log level name to a log.Severity,
+// falling back to log.WarningLevel for unknown values.
+func parseLogLevel(level string) log.Severity {
+	switch level {
+	case "debug":
+		return log.DebugLevel
+	case "info":
+		return log.InfoLevel
+	case "warning":
+		return log.WarningLevel
+	case "error":
+		return log.ErrorLevel
+	default:
+		slog.Warn("unknown log level, falling back to warning", "level", level)
+		return log.WarningLevel
+	}
+}
+
 func printStackTo(writer io.Writer, msg string) {
 	_, err := fmt.Fprintf(writer, "===== %s =====\n", msg)
 	if err == nil {
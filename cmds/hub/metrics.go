@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/safing/portmaster/base/api"
+	"github.com/safing/portmaster/base/config"
+	"github.com/safing/portmaster/base/metrics"
+)
+
+// metricsShutdownGrace is how long the metrics server is given to drain
+// in-flight requests during shutdown.
+const metricsShutdownGrace = 5 * time.Second
+
+// hubInstance is the subset of *spn.Instance the metrics server needs.
+type hubInstance interface {
+	Ready() bool
+	Stopped() <-chan struct{}
+}
+
+// startMetricsServer starts a dedicated HTTP server exposing Prometheus
+// metrics on its own mux, plus /health and /ready endpoints backed by the
+// given instance. It returns nil if no listen address was configured.
+func startMetricsServer(cfg *bootConfig, instance hubInstance) (*http.Server, error) {
+	if cfg.MetricsListen == "" {
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", basicAuth(cfg, metricsHandler()))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-instance.Stopped():
+			http.Error(w, "stopped", http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if instance.Ready() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+	})
+
+	srv := &http.Server{
+		Addr:              cfg.MetricsListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	useTLS := cfg.MetricsTLSCert != "" && cfg.MetricsTLSKey != ""
+	go func() {
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS(cfg.MetricsTLSCert, cfg.MetricsTLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "err", err)
+		}
+	}()
+
+	slog.Info("started metrics server", "listen", cfg.MetricsListen, "tls", useTLS)
+	return srv, nil
+}
+
+// stopMetricsServer gracefully drains and stops the metrics server.
+func stopMetricsServer(srv *http.Server) {
+	if srv == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownGrace)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("metrics server did not shut down cleanly", "err", err)
+	}
+}
+
+func basicAuth(cfg *bootConfig, next http.Handler) http.Handler {
+	if cfg.MetricsBasicAuthUser == "" && cfg.MetricsBasicAuthPass == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.MetricsBasicAuthUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.MetricsBasicAuthPass)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricsHandler wraps metrics.WriteMetrics in a plain http.Handler. The
+// gauges it serves (Go runtime, process, and SPN-specific) are the ones
+// already registered against the shared registry by their owning packages;
+// this handler only needs to render the current registry.
+func metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.WriteMetrics(w, api.PermitAnyone, config.ExpertiseLevelUser)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// startPprofServer starts a dedicated HTTP server exposing net/http/pprof
+// handlers and a /debug/stack endpoint. It returns nil if no listen address
+// was configured. By default the listener is restricted to loopback
+// addresses; binding to a public interface requires -pprof-allow-public.
+func startPprofServer(cfg *bootConfig) (*http.Server, error) {
+	if cfg.PprofListen == "" {
+		return nil, nil
+	}
+
+	if !cfg.PprofAllowPublic {
+		if err := checkLoopbackAddr(cfg.PprofListen); err != nil {
+			return nil, fmt.Errorf("refusing to start pprof server: %w (pass -pprof-allow-public to override)", err)
+		}
+	}
+
+	if cfg.PprofBlockRate > 0 {
+		runtime.SetBlockProfileRate(cfg.PprofBlockRate)
+	}
+	if cfg.PprofMutexFraction > 0 {
+		runtime.SetMutexProfileFraction(cfg.PprofMutexFraction)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/stack", func(w http.ResponseWriter, r *http.Request) {
+		printStackTo(w, "PRINTING STACK ON REQUEST")
+	})
+
+	srv := &http.Server{
+		Addr:              cfg.PprofListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("pprof server failed", "err", err)
+		}
+	}()
+
+	slog.Info("started pprof server", "listen", cfg.PprofListen)
+	return srv, nil
+}
+
+// stopPprofServer gracefully stops the pprof server.
+func stopPprofServer(srv *http.Server) {
+	if srv == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("pprof server did not shut down cleanly", "err", err)
+	}
+}
+
+// checkLoopbackAddr returns an error unless addr's host is a loopback IP
+// literal or "localhost"; other host names are not resolved and are
+// rejected. An empty host (e.g. ":6060") is treated as a wildcard bind and
+// is not considered loopback.
+func checkLoopbackAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "" {
+		return fmt.Errorf("listen address %q binds to all interfaces", addr)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Not an IP literal (e.g. "localhost"); accept common loopback names.
+		if strings.EqualFold(host, "localhost") {
+			return nil
+		}
+		return fmt.Errorf("listen address %q is neither a loopback IP nor \"localhost\"", addr)
+	}
+	if !ip.IsLoopback() {
+		return fmt.Errorf("listen address %q is not a loopback address", addr)
+	}
+	return nil
+}
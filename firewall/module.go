@@ -1,93 +1,125 @@
 package firewall
 
 import (
-	"context"
-
-	"github.com/safing/portbase/config"
-	"github.com/safing/portbase/log"
-	"github.com/safing/portbase/modules"
-	"github.com/safing/portbase/modules/subsystems"
-	_ "github.com/safing/portmaster/core"
+	"github.com/safing/portmaster/base/config"
+	"github.com/safing/portmaster/base/log"
 	"github.com/safing/portmaster/network"
+	"github.com/safing/portmaster/profile"
+	"github.com/safing/portmaster/service/mgr"
+	"github.com/safing/portmaster/spn/captain"
 )
 
-var module *modules.Module
-
-func init() {
-	module = modules.Register("filter", prep, start, stop, "core", "interception", "intel")
-	subsystems.Register(
-		"filter",
-		"Privacy Filter",
-		"DNS and Network Filter",
-		module,
-		"config:filter/",
-		&config.Option{
-			Name:           "Privacy Filter Module",
-			Key:            CfgOptionEnableFilterKey,
-			Description:    "Start the Privacy Filter module. If turned off, all privacy filter protections are fully disabled on this device.",
-			OptType:        config.OptTypeBool,
-			ExpertiseLevel: config.ExpertiseLevelDeveloper,
-			ReleaseLevel:   config.ReleaseLevelStable,
-			DefaultValue:   true,
-			Annotations: config.Annotations{
-				config.CategoryAnnotation: "General",
-			},
+// FirewallModule integrates the privacy filter into the service/mgr module
+// tree. It replaces the former portbase/modules-based "filter" module.
+type FirewallModule struct {
+	mgr      *mgr.Manager
+	instance instance
+}
+
+// Manager returns the module's service/mgr manager.
+func (fw *FirewallModule) Manager() *mgr.Manager {
+	return fw.mgr
+}
+
+// Start starts the module.
+func (fw *FirewallModule) Start() error {
+	getConfig()
+	startAPIAuth()
+
+	fw.mgr.Go("packet handler", packetHandler)
+	fw.mgr.Go("bandwidth update handler", bandwidthUpdateHandler)
+
+	// Start stat logger if logging is set to trace.
+	if log.GetLogLevel() == log.TraceLevel {
+		fw.mgr.Go("stat logger", statLogger)
+	}
+
+	return nil
+}
+
+// Stop stops the module.
+func (fw *FirewallModule) Stop() error {
+	return nil
+}
+
+// instance describes the dependencies the firewall module needs from the
+// running instance.
+type instance interface {
+	Config() *config.Config
+	Profile() *profile.ProfileModule
+	Captain() *captain.Captain
+}
+
+// New creates a new firewall module, replacing the former
+// modules.Register("filter", ...) registration.
+func New(instance instance) (*FirewallModule, error) {
+	m := mgr.New("filter")
+	fw := &FirewallModule{
+		mgr:      m,
+		instance: instance,
+	}
+	module = fw
+
+	if err := prep(fw); err != nil {
+		return nil, err
+	}
+
+	if err := config.Register(&config.Option{
+		Name:           "Privacy Filter Module",
+		Key:            CfgOptionEnableFilterKey,
+		Description:    "Start the Privacy Filter module. If turned off, all privacy filter protections are fully disabled on this device.",
+		OptType:        config.OptTypeBool,
+		ExpertiseLevel: config.ExpertiseLevelDeveloper,
+		ReleaseLevel:   config.ReleaseLevelStable,
+		DefaultValue:   true,
+		Annotations: config.Annotations{
+			config.CategoryAnnotation: "General",
 		},
-	)
+	}); err != nil {
+		return nil, err
+	}
+
+	return fw, nil
 }
 
-const (
-	configChangeEvent        = "config change"
-	profileConfigChangeEvent = "profile config change"
-	onSPNConnectEvent        = "spn connect"
-)
+// module holds the last created FirewallModule.
+//
+// Deprecated: use the *FirewallModule instance returned by New instead of
+// reaching for the package-level module. The var is unexported, so only code
+// inside package firewall can refer to it.
+var module *FirewallModule
 
-func prep() error {
+func prep(fw *FirewallModule) error {
 	network.SetDefaultFirewallHandler(verdictHandler)
 
 	// Reset connections every time configuration changes
 	// this will be triggered on spn enable/disable
-	err := module.RegisterEventHook(
-		"config",
-		configChangeEvent,
+	fw.instance.Config().EventConfigChange.AddCallback(
 		"reset connection verdicts",
-		func(ctx context.Context, _ interface{}) error {
+		func(_ *mgr.WorkerCtx, _ struct{}) (bool, error) {
 			resetAllConnectionVerdicts()
-			return nil
+			return false, nil
 		},
 	)
-	if err != nil {
-		log.Errorf("interception: failed registering event hook: %s", err)
-	}
 
 	// Reset connections every time profile changes
-	err = module.RegisterEventHook(
-		"profiles",
-		profileConfigChangeEvent,
+	fw.instance.Profile().EventProfileConfigChange.AddCallback(
 		"reset connection verdicts",
-		func(ctx context.Context, _ interface{}) error {
+		func(_ *mgr.WorkerCtx, _ struct{}) (bool, error) {
 			resetAllConnectionVerdicts()
-			return nil
+			return false, nil
 		},
 	)
-	if err != nil {
-		log.Errorf("failed registering event hook: %s", err)
-	}
 
 	// Reset connections when spn is connected
 	// connect and disconnecting is triggered on config change event but connecting takеs more time
-	err = module.RegisterEventHook(
-		"captain",
-		onSPNConnectEvent,
+	fw.instance.Captain().EventSPNConnect.AddCallback(
 		"reset connection verdicts",
-		func(ctx context.Context, _ interface{}) error {
+		func(_ *mgr.WorkerCtx, _ struct{}) (bool, error) {
 			resetAllConnectionVerdicts()
-			return nil
+			return false, nil
 		},
 	)
-	if err != nil {
-		log.Errorf("failed registering event hook: %s", err)
-	}
 
 	if err := registerConfig(); err != nil {
 		return err
@@ -95,22 +127,3 @@ func prep() error {
 
 	return prepAPIAuth()
 }
-
-func start() error {
-	getConfig()
-	startAPIAuth()
-
-	module.StartServiceWorker("packet handler", 0, packetHandler)
-	module.StartServiceWorker("bandwidth update handler", 0, bandwidthUpdateHandler)
-
-	// Start stat logger if logging is set to trace.
-	if log.GetLogLevel() == log.TraceLevel {
-		module.StartServiceWorker("stat logger", 0, statLogger)
-	}
-
-	return nil
-}
-
-func stop() error {
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,102 @@
+// Package sdnotify implements the systemd service notification protocol
+// (sd_notify(3)), so the hub can declare readiness and feed a watchdog
+// without linking against libsystemd. Every function is a no-op when
+// $NOTIFY_SOCKET (or $WATCHDOG_USEC, for the watchdog) is not set, so
+// nothing changes on non-systemd hosts.
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notify sends a raw state string to the systemd notification socket given
+// in $NOTIFY_SOCKET. It reports false, nil if the socket is not configured.
+func Notify(state string) (bool, error) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return false, nil
+	}
+
+	// Per sd_notify(3), an address starting with "@" refers to the Linux
+	// abstract namespace and must have the "@" replaced with a NUL byte.
+	if strings.HasPrefix(socketAddr, "@") {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.Dial("unixgram", socketAddr)
+	if err != nil {
+		return false, fmt.Errorf("sdnotify: failed to connect to %s: %w", socketAddr, err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("sdnotify: failed to write to %s: %w", socketAddr, err)
+	}
+	return true, nil
+}
+
+// Ready tells systemd that startup is finished and the service is ready to
+// accept requests. Call this once instance.Ready() reports true.
+func Ready() (bool, error) {
+	return Notify("READY=1")
+}
+
+// Stopping tells systemd that the service is beginning its shutdown.
+func Stopping() (bool, error) {
+	return Notify("STOPPING=1")
+}
+
+// Status sends a free-form single-line status message that shows up in
+// `systemctl status`.
+func Status(msg string) (bool, error) {
+	return Notify("STATUS=" + msg)
+}
+
+// WatchdogInterval returns the interval at which WATCHDOG=1 must be sent to
+// satisfy systemd's WatchdogSec=, derived from $WATCHDOG_USEC. It returns
+// false if no watchdog is configured for this process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * time.Microsecond, true
+}
+
+// StartWatchdog starts a goroutine that pings WATCHDOG=1 at half the
+// interval given in $WATCHDOG_USEC, until ctx is done. If no watchdog
+// interval is configured, it does nothing and returns false.
+func StartWatchdog(ctx context.Context) bool {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return false
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = Notify("WATCHDOG=1")
+			}
+		}
+	}()
+
+	return true
+}